@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/ausdomainledger/web/internal/logging"
+	"github.com/ausdomainledger/web/internal/reqid"
+	"github.com/go-chi/chi/middleware"
+)
+
+// sqlCorrelation carries the one SQL statement a request's handler ran
+// (if any) through to accessLogMiddleware, so a failing query shows up
+// on the same JSON line as the request it failed, rather than as a
+// separate unlinked log entry.
+type sqlCorrelation struct {
+	statement string
+	rows      int
+	err       string
+}
+
+type sqlCtxKey struct{}
+
+func withSQLCorrelation(ctx context.Context) (context.Context, *sqlCorrelation) {
+	c := &sqlCorrelation{}
+	return context.WithValue(ctx, sqlCtxKey{}, c), c
+}
+
+func sqlCorrelationFromContext(ctx context.Context) *sqlCorrelation {
+	c, _ := ctx.Value(sqlCtxKey{}).(*sqlCorrelation)
+	return c
+}
+
+// recordSQLCorrelation attaches stmtName's outcome to ctx's
+// sqlCorrelation, if one was installed by accessLogMiddleware. dest must
+// be the same *[]queryResult passed to db.SelectContext, so the row
+// count can be read back without an extra query.
+func recordSQLCorrelation(ctx context.Context, stmtName string, dest interface{}, err error) {
+	c := sqlCorrelationFromContext(ctx)
+	if c == nil {
+		return
+	}
+	c.statement = stmtName
+	if err != nil {
+		c.err = err.Error()
+		return
+	}
+	if rows, ok := dest.(*[]queryResult); ok {
+		c.rows = len(*rows)
+	}
+}
+
+// accessLogMiddleware logs one structured JSON line per request: method,
+// path, remote IP (respecting trusted proxies), status, bytes written,
+// duration, the request ID reqid.Middleware assigned, and whatever SQL
+// statement the handler ran, so a user-visible 500 can be traced
+// straight to the failing query.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, sql := withSQLCorrelation(r.Context())
+		r = r.WithContext(ctx)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		fields := logging.Fields{
+			"request_id":  reqid.FromContext(r.Context()),
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"remote_ip":   clientIPKey(r),
+			"status":      ww.Status(),
+			"bytes":       ww.BytesWritten(),
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if sql.statement != "" {
+			fields["sql_statement"] = sql.statement
+			fields["sql_rows"] = sql.rows
+			if sql.err != "" {
+				fields["sql_error"] = sql.err
+			}
+		}
+		accessLog.Log(fields)
+	})
+}
+
+// recoverer is middleware.Recoverer's job, but it reports the panic and
+// its stack trace as a structured log line instead of chi's default
+// ANSI-colored stderr dump, so it lands in the same log stream (and can
+// carry the same request ID) as everything else.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil && rec != http.ErrAbortHandler {
+				accessLog.Log(logging.Fields{
+					"level":      "error",
+					"request_id": reqid.FromContext(r.Context()),
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"panic":      rec,
+					"stack":      string(debug.Stack()),
+				})
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -9,32 +9,73 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ausdomainledger/web/internal/logging"
+	"github.com/ausdomainledger/web/internal/metrics"
+	"github.com/ausdomainledger/web/internal/querydsl"
+	"github.com/ausdomainledger/web/internal/ratelimit"
+	"github.com/ausdomainledger/web/internal/reqid"
+	"github.com/ausdomainledger/web/internal/stats"
+	"github.com/ausdomainledger/web/internal/stream"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/tsenart/tb"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// version and commit are overridden at build time via
+// -ldflags "-X main.version=... -X main.commit=...".
+var (
+	version = "dev"
+	commit  = "none"
+)
+
 var (
-	throttler        *tb.Throttler
 	db               *sqlx.DB
 	throttleDisabled bool
 
-	errThrottled = errors.New("Throttled")
+	globalLimiter    *ratelimit.Limiter
+	queryLimiter     *ratelimit.Limiter
+	expensiveLimiter *ratelimit.Limiter
+	trustedProxies   []*net.IPNet
+
+	statsCache *stats.Cache
+
+	metricsRegistry  *metrics.Registry
+	httpMetrics      *metrics.HTTPMetrics
+	throttleRejected *metrics.Counter
+	sqlDuration      *metrics.HistogramVec
 
-	etldCount   int64
-	domainCount int64
+	accessLog *logging.Logger
+)
+
+// Default rate quotas, overridable via LEDGER_WEB_RATE_GLOBAL,
+// LEDGER_WEB_RATE_QUERY and LEDGER_WEB_RATE_EXPENSIVE (format
+// "<count>/<s|m|h>[:<burst>]", e.g. "5/s:10").
+const (
+	defaultGlobalRate    = "200/s:50"
+	defaultQueryRate     = "5/s:10"
+	defaultExpensiveRate = "1/s:2"
+
+	// defaultStatsRecountInterval bounds how stale statsCache can get
+	// between a missed notification and the next full recount, and is
+	// overridable via LEDGER_WEB_STATS_RECOUNT_INTERVAL (minutes).
+	defaultStatsRecountInterval = 5 * time.Minute
+
+	// rateLimiterCapacity bounds how many distinct IPs each limiter
+	// tracks at once; least-recently-seen keys are evicted past this.
+	rateLimiterCapacity = 100000
 )
 
 type queryResponse struct {
@@ -50,11 +91,6 @@ type queryResult struct {
 	Id        uint64 `json:"id" db:"id"`
 }
 
-type statsResponse struct {
-	DomainCount int64 `json:"domains"`
-	ETLDCount   int64 `json:"etlds"`
-}
-
 func main() {
 	var err error
 	db, err = sqlx.Open("postgres", os.Getenv("LEDGER_WEB_DSN"))
@@ -62,12 +98,49 @@ func main() {
 		log.Fatal(err)
 	}
 
+	setupMetrics()
+
+	statsCache = stats.NewCache(db, observeSQL)
+	metricsRegistry.GaugeFunc("domains_total", "Cached count of rows in the domains table.", func() float64 {
+		return float64(statsCache.Snapshot().Domains)
+	})
+	metricsRegistry.GaugeFunc("etlds_total", "Cached count of distinct eTLDs in the domains table.", func() float64 {
+		return float64(statsCache.Snapshot().ETLDs)
+	})
+
+	statsCtx, cancelStats := context.WithCancel(context.Background())
+	defer cancelStats()
+	go func() {
+		if err := statsCache.Run(statsCtx, os.Getenv("LEDGER_WEB_DSN"), statsRecountInterval()); err != nil && statsCtx.Err() == nil {
+			log.Printf("stats: cache exited: %v", err)
+		}
+	}()
+
 	throttleDisabled = os.Getenv("LEDGER_WEB_NOTHROTTLE") != ""
 	if !throttleDisabled {
-		throttler = tb.NewThrottler(time.Second)
-		defer throttler.Close()
+		if trustedProxies, err = ratelimit.ParseTrustedProxies(os.Getenv("LEDGER_WEB_TRUSTED_PROXIES")); err != nil {
+			log.Fatalf("Invalid LEDGER_WEB_TRUSTED_PROXIES: %v", err)
+		}
+
+		globalLimiter = mustLimiter("LEDGER_WEB_RATE_GLOBAL", defaultGlobalRate)
+		queryLimiter = mustLimiter("LEDGER_WEB_RATE_QUERY", defaultQueryRate)
+		expensiveLimiter = mustLimiter("LEDGER_WEB_RATE_EXPENSIVE", defaultExpensiveRate)
+		streamConcurrency = ratelimit.NewConcurrencyLimiter(streamMaxPerIP())
 	}
 
+	if adminListen := os.Getenv("LEDGER_WEB_ADMIN_LISTEN"); adminListen != "" {
+		go serveAdmin(adminListen)
+	}
+
+	streamHub = stream.NewHub()
+	streamCtx, cancelStream := context.WithCancel(context.Background())
+	defer cancelStream()
+	go func() {
+		if err := stream.Listen(streamCtx, os.Getenv("LEDGER_WEB_DSN"), streamHub); err != nil && streamCtx.Err() == nil {
+			log.Printf("stream: listener exited: %v", err)
+		}
+	}()
+
 	r := chi.NewRouter()
 
 	xo := cors.New(cors.Options{
@@ -76,23 +149,37 @@ func main() {
 		AllowedHeaders: []string{"Content-Type", "Accept"},
 		MaxAge:         300,
 	})
+	r.Use(reqid.Middleware)
 	r.Use(middleware.CloseNotify)
-	r.Use(middleware.Timeout(10 * time.Second))
 	r.Use(xo.Handler)
-	r.Use(middleware.DefaultCompress)
-	r.Use(middleware.Recoverer)
+	r.Use(httpMetrics.Middleware)
+	r.Use(accessLogMiddleware)
+	r.Use(recoverer)
 
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/stats", statsHandler)
+		// /stats and /query get the usual request timeout and response
+		// compression; /stream is mounted as a sibling group below
+		// without either, since both break long-lived SSE connections.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(10 * time.Second))
+			r.Use(middleware.DefaultCompress)
+
+			r.Get("/stats", statsHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(checkGlobalLimit)
+				r.Use(checkQueryLimit)
+				r.Get("/query", queryHandler)
+			})
+		})
 
 		r.Group(func(r chi.Router) {
-			r.Use(checkLimit)
-			r.Get("/query", queryHandler)
+			r.Use(checkGlobalLimit)
+			r.Use(checkStreamLimit)
+			r.Get("/stream", streamHandler)
 		})
 	})
 
-	go pollEtldCount()
-
 	if ssl := os.Getenv("LEDGER_WEB_SSL"); ssl != "" {
 		log.Fatal(http.Serve(autocert.NewListener(ssl), r))
 	}
@@ -114,7 +201,7 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 
 	res, err := query(ctx, q.Get("query"), off, last, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeQueryError(w, err)
 		return
 	}
 
@@ -122,6 +209,27 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(res)
 }
 
+// writeQueryError reports a failed query as a 400 with a JSON body. DSL
+// parse failures carry the rune position of the offending token so
+// clients can point the user at it.
+func writeQueryError(w http.ResponseWriter, err error) {
+	type errResponse struct {
+		Error string `json:"error"`
+		Pos   *int   `json:"pos,omitempty"`
+	}
+
+	resp := errResponse{Error: err.Error()}
+	var perr *querydsl.ParseError
+	if errors.As(err, &perr) {
+		resp.Error = perr.Msg
+		resp.Pos = &perr.Pos
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(resp)
+}
+
 func query(ctx context.Context, qs string, fromTime int, lastId int, limit int) (queryResponse, error) {
 	if limit == 0 || limit > 1000 {
 		limit = 1000
@@ -134,21 +242,25 @@ func query(ctx context.Context, qs string, fromTime int, lastId int, limit int)
 		return queryResponse{}, errors.New("Query must be at least 3 characters")
 	}
 
-	qs = strings.ToLower(strings.TrimSpace(qs))
-
-	var out []queryResult
+	where, args, err := querydsl.ParseAndCompile(qs, 0)
+	if err != nil {
+		return queryResponse{}, err
+	}
 
-	var err error
+	stmt := "SELECT * FROM domains WHERE " + where
+	if fromTime > 0 {
+		args = append(args, fromTime)
+		stmt += fmt.Sprintf(" AND first_seen <= $%d", len(args))
+	}
 	if fromTime > 0 && lastId > 0 {
-		err = db.SelectContext(ctx, &out, "SELECT * FROM domains WHERE domain LIKE $1 AND first_seen <= $2 AND id < $4 ORDER BY first_seen DESC, last_seen DESC, id DESC LIMIT $3;", qs, fromTime, limit, lastId)
-	} else if lastId == 0 && fromTime > 0 {
-		err = db.SelectContext(ctx, &out, "SELECT * FROM domains WHERE domain LIKE $1 AND first_seen <= $2 ORDER BY first_seen DESC, last_seen DESC, id DESC LIMIT $3;", qs, fromTime, limit)
-	} else {
-		err = db.SelectContext(ctx, &out, "SELECT * FROM domains WHERE domain LIKE $1 ORDER BY first_seen DESC, last_seen DESC, id DESC LIMIT $2;", qs, limit)
+		args = append(args, lastId)
+		stmt += fmt.Sprintf(" AND id < $%d", len(args))
 	}
+	args = append(args, limit)
+	stmt += fmt.Sprintf(" ORDER BY first_seen DESC, last_seen DESC, id DESC LIMIT $%d;", len(args))
 
-	if err != nil {
-		log.Printf("Query error: %v", err)
+	var out []queryResult
+	if err := dbSelect(ctx, "query", stmt, &out, args...); err != nil {
 		return queryResponse{}, errors.New("Query failed :(")
 	}
 
@@ -162,38 +274,123 @@ func query(ctx context.Context, qs string, fromTime int, lastId int, limit int)
 	return queryResponse{Results: out, Last: lowestId}, nil
 }
 
-func checkLimit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if throttleDisabled {
-			next.ServeHTTP(w, r)
-			return
+// statsRecountInterval reads LEDGER_WEB_STATS_RECOUNT_INTERVAL, in
+// minutes, falling back to defaultStatsRecountInterval.
+func statsRecountInterval() time.Duration {
+	if v := os.Getenv("LEDGER_WEB_STATS_RECOUNT_INTERVAL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Minute
 		}
-		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-		if throttler.Halt(ip, 1, 5) {
-			http.Error(w, "Throttled", 429)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+	}
+	return defaultStatsRecountInterval
 }
 
-func pollEtldCount() {
-	for {
-		if err := db.Get(&etldCount, "SELECT COUNT(*) FROM (SELECT DISTINCT etld FROM domains) AS temp;"); err != nil {
-			log.Printf("Failed to get etld count: %v", err)
-		}
+func mustLimiter(env, def string) *ratelimit.Limiter {
+	s := os.Getenv(env)
+	if s == "" {
+		s = def
+	}
+	quota, err := ratelimit.ParseQuota(s)
+	if err != nil {
+		log.Fatalf("%s: %v", env, err)
+	}
+	return ratelimit.NewLimiter(quota, rateLimiterCapacity)
+}
 
-		if err := db.Get(&domainCount, "SELECT COUNT(*) FROM domains"); err != nil {
-			log.Printf("Failed to get domain count: %v", err)
-		}
-		time.Sleep(time.Minute)
+// setupMetrics registers the Prometheus collectors and the access-log
+// writer used throughout the package. It must run before any handler can
+// be reached, since httpMetrics.Middleware and accessLogMiddleware are
+// installed on the router.
+func setupMetrics() {
+	accessLog = logging.New(os.Stderr)
+
+	metricsRegistry = metrics.NewRegistry()
+	metrics.RegisterRuntimeCollectors(metricsRegistry)
+
+	httpMetrics = metrics.NewHTTPMetrics(metricsRegistry)
+	throttleRejected = metricsRegistry.Counter("throttle_rejections_total", "Requests rejected by a rate or concurrency limiter.")
+	sqlDuration = metricsRegistry.HistogramVec("sql_query_duration_seconds", "Duration of SQL statements issued to Postgres.", metrics.DefaultLatencyBuckets, "statement")
+
+	buildInfo := metricsRegistry.GaugeVec("build_info", "Static build metadata, always 1.", "version", "commit", "goversion")
+	buildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
+
+// observeSQL records d against the sql_query_duration_seconds histogram
+// for the given statement name; it is passed to stats.NewCache so its
+// recounts land in the same metric as every other query.
+func observeSQL(stmtName string, d time.Duration) {
+	sqlDuration.WithLabelValues(stmtName).Observe(d.Seconds())
+}
+
+// serveAdmin runs the admin-only HTTP server exposing /metrics on addr.
+// It is meant to be bound to a local or otherwise non-public interface;
+// the endpoint itself carries no authentication.
+func serveAdmin(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsRegistry.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("admin: listener exited: %v", err)
 	}
 }
 
+// dbSelect wraps db.SelectContext, recording its duration in the
+// sql_query_duration_seconds histogram labeled by the statement name
+// (not the literal SQL, which varies per query and would blow up label
+// cardinality).
+func dbSelect(ctx context.Context, stmtName, query string, dest interface{}, args ...interface{}) error {
+	start := time.Now()
+	err := db.SelectContext(ctx, dest, query, args...)
+	observeSQL(stmtName, time.Since(start))
+	recordSQLCorrelation(ctx, stmtName, dest, err)
+	return err
+}
+
+func clientIPKey(r *http.Request) string {
+	return ratelimit.ClientIP(r, trustedProxies)
+}
+
+// isExpensiveQuery reports whether qs is the kind of search that forces
+// Postgres into a broad scan rather than a selective index lookup, e.g. a
+// bare wildcard or a very short prefix.
+func isExpensiveQuery(qs string) bool {
+	qs = strings.TrimSpace(qs)
+	if len(qs) < 5 {
+		return true
+	}
+	return strings.ContainsAny(qs, "%_*?")
+}
+
+// checkGlobalLimit enforces a single system-wide quota shared by every
+// client, protecting the database from an aggregate flood even when no
+// individual IP is over its own limit.
+func checkGlobalLimit(next http.Handler) http.Handler {
+	if throttleDisabled {
+		return next
+	}
+	return ratelimit.Middleware(globalLimiter, func(*http.Request) string { return "global" }, throttleRejected.Inc)(next)
+}
+
+// checkQueryLimit applies the normal per-IP quota to /query, except
+// unmatched or expensive-looking searches (bare wildcards, very short
+// prefixes) which are routed through the stricter expensiveLimiter instead.
+func checkQueryLimit(next http.Handler) http.Handler {
+	if throttleDisabled {
+		return next
+	}
+
+	normal := ratelimit.Middleware(queryLimiter, clientIPKey, throttleRejected.Inc)(next)
+	expensive := ratelimit.Middleware(expensiveLimiter, clientIPKey, throttleRejected.Inc)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isExpensiveQuery(r.URL.Query().Get("query")) {
+			expensive.ServeHTTP(w, r)
+			return
+		}
+		normal.ServeHTTP(w, r)
+	})
+}
+
 func statsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(statsResponse{
-		DomainCount: domainCount,
-		ETLDCount:   etldCount,
-	})
+	json.NewEncoder(w).Encode(statsCache.Snapshot())
 }
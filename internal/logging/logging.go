@@ -0,0 +1,45 @@
+// Package logging implements a minimal structured JSON-lines logger for
+// access logs and panic reports that need to carry request-scoped
+// fields (request ID, SQL statement, ...) rather than a single free-text
+// message.
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Fields is a single structured log entry. Keys are written as supplied,
+// so callers should stick to consistent, lowercase, snake_case names.
+type Fields map[string]interface{}
+
+// Logger writes Fields as JSON lines to out, serializing concurrent
+// writers so lines are never interleaved.
+type Logger struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// New returns a Logger writing to out.
+func New(out io.Writer) *Logger {
+	return &Logger{out: out}
+}
+
+// Log writes fields as one JSON line, stamping it with the current time.
+// Marshaling failures are dropped rather than propagated, since a
+// logging call must never be the reason a request fails.
+func (l *Logger) Log(fields Fields) {
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
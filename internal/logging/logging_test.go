@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogWritesOneJSONLinePerCall(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf)
+
+	l.Log(Fields{"method": "GET", "status": 200})
+	l.Log(Fields{"method": "POST", "status": 500})
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("line 1 is not valid JSON: %v", err)
+	}
+	if entry["method"] != "GET" {
+		t.Errorf("expected method GET, got %v", entry["method"])
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("expected a time field to be stamped onto the entry")
+	}
+}
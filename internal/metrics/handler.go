@@ -0,0 +1,12 @@
+package metrics
+
+import "net/http"
+
+// Handler returns an http.Handler serving r in the Prometheus text
+// exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
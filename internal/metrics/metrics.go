@@ -0,0 +1,339 @@
+// Package metrics implements a small Prometheus exposition-format
+// registry: counters, gauges and histograms, with label support for the
+// handful of cardinality-bounded dimensions (route, status, SQL
+// statement name) this service needs. It deliberately does not pull in
+// the full client_golang dependency tree.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing value, e.g. a request count.
+type Counter struct {
+	v atomic.Int64
+}
+
+// Inc increments c by one.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Add increments c by n, which must be non-negative.
+func (c *Counter) Add(n int64) { c.v.Add(n) }
+
+func (c *Counter) value() float64 { return float64(c.v.Load()) }
+
+// Gauge is a value that can go up or down, e.g. an in-flight request
+// count or a cached row count.
+type Gauge struct {
+	v atomic.Int64
+}
+
+// Set sets g to n.
+func (g *Gauge) Set(n int64) { g.v.Store(n) }
+
+// Inc increments g by one.
+func (g *Gauge) Inc() { g.v.Add(1) }
+
+// Dec decrements g by one.
+func (g *Gauge) Dec() { g.v.Add(-1) }
+
+func (g *Gauge) value() float64 { return float64(g.v.Load()) }
+
+// Histogram tracks the distribution of observed values (e.g. request
+// latency in seconds) across a fixed set of cumulative buckets, in the
+// shape the Prometheus text format expects: bucket i counts every
+// observation <= buckets[i].
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Int64
+	sumBits atomic.Uint64
+	total   atomic.Int64
+}
+
+// NewHistogram returns a Histogram with the given ascending bucket upper
+// bounds. An implicit "+Inf" bucket covering every observation is added
+// automatically when written.
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Int64, len(buckets))}
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i].Add(1)
+		}
+	}
+	h.total.Add(1)
+	for {
+		old := h.sumBits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if h.sumBits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) sum() float64 { return math.Float64frombits(h.sumBits.Load()) }
+
+// DefaultLatencyBuckets are Prometheus's standard buckets for
+// sub-ten-second latencies, in seconds.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricKind is the Prometheus TYPE comment value for a registered family.
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// family is a registered metric name together with however many
+// label-keyed children it has accumulated (one, for an unlabeled metric).
+type family struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+
+	mu       sync.Mutex
+	children map[string]labeledValue
+}
+
+type labeledValue struct {
+	labelValues []string
+	counter     *Counter
+	gauge       *Gauge
+	gaugeFunc   func() float64
+	histogram   *Histogram
+}
+
+// Registry collects metric families and renders them in the Prometheus
+// text exposition format.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]*family)}
+}
+
+func (r *Registry) newFamily(name, help string, kind metricKind, labelNames ...string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.byName[name]; exists {
+		panic("metrics: duplicate registration of " + name)
+	}
+
+	f := &family{name: name, help: help, kind: kind, labelNames: labelNames, children: make(map[string]labeledValue)}
+	r.byName[name] = f
+	r.families = append(r.families, f)
+	return f
+}
+
+// Counter registers and returns a new unlabeled Counter.
+func (r *Registry) Counter(name, help string) *Counter {
+	f := r.newFamily(name, help, kindCounter)
+	c := &Counter{}
+	f.children[""] = labeledValue{counter: c}
+	return c
+}
+
+// Gauge registers and returns a new unlabeled Gauge.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	f := r.newFamily(name, help, kindGauge)
+	g := &Gauge{}
+	f.children[""] = labeledValue{gauge: g}
+	return g
+}
+
+// GaugeFunc registers a gauge whose value is computed by calling fn at
+// scrape time, for metrics (like runtime stats) that are cheap to read
+// but not worth keeping continuously updated.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	f := r.newFamily(name, help, kindGauge)
+	f.children[""] = labeledValue{gaugeFunc: fn}
+}
+
+// Histogram registers and returns a new unlabeled Histogram.
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	f := r.newFamily(name, help, kindHistogram)
+	h := NewHistogram(buckets)
+	f.children[""] = labeledValue{histogram: h}
+	return h
+}
+
+// CounterVec is a Counter family varying over a fixed set of label names.
+type CounterVec struct{ f *family }
+
+// CounterVec registers and returns a new CounterVec with the given label
+// names, in the order they must be supplied to WithLabelValues.
+func (r *Registry) CounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{f: r.newFamily(name, help, kindCounter, labelNames...)}
+}
+
+// WithLabelValues returns the Counter for the given label values,
+// creating it on first use. values must match the order labelNames was
+// registered with.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	f := v.f
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lv, ok := f.children[key]; ok {
+		return lv.counter
+	}
+	c := &Counter{}
+	f.children[key] = labeledValue{labelValues: values, counter: c}
+	return c
+}
+
+// GaugeVec is a Gauge family varying over a fixed set of label names.
+type GaugeVec struct{ f *family }
+
+// GaugeVec registers and returns a new GaugeVec.
+func (r *Registry) GaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{f: r.newFamily(name, help, kindGauge, labelNames...)}
+}
+
+// WithLabelValues returns the Gauge for the given label values, creating
+// it on first use.
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	f := v.f
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lv, ok := f.children[key]; ok {
+		return lv.gauge
+	}
+	g := &Gauge{}
+	f.children[key] = labeledValue{labelValues: values, gauge: g}
+	return g
+}
+
+// HistogramVec is a Histogram family varying over a fixed set of label
+// names.
+type HistogramVec struct {
+	f       *family
+	buckets []float64
+}
+
+// HistogramVec registers and returns a new HistogramVec.
+func (r *Registry) HistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{f: r.newFamily(name, help, kindHistogram, labelNames...), buckets: buckets}
+}
+
+// WithLabelValues returns the Histogram for the given label values,
+// creating it on first use.
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	f := v.f
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lv, ok := f.children[key]; ok {
+		return lv.histogram
+	}
+	h := NewHistogram(v.buckets)
+	f.children[key] = labeledValue{labelValues: values, histogram: h}
+	return h
+}
+
+// Write renders every registered family in the Prometheus text
+// exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	bw := &errWriter{w: w}
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		f.mu.Lock()
+		keys := make([]string, 0, len(f.children))
+		for k := range f.children {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			lv := f.children[k]
+			labels := formatLabels(f.labelNames, lv.labelValues)
+
+			switch f.kind {
+			case kindCounter:
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, labels, formatFloat(lv.counter.value()))
+			case kindGauge:
+				val := 0.0
+				if lv.gaugeFunc != nil {
+					val = lv.gaugeFunc()
+				} else {
+					val = lv.gauge.value()
+				}
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, labels, formatFloat(val))
+			case kindHistogram:
+				h := lv.histogram
+				for i, bound := range h.buckets {
+					bl := formatLabels(append(append([]string{}, f.labelNames...), "le"), append(append([]string{}, lv.labelValues...), formatFloat(bound)))
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, bl, h.counts[i].Load())
+				}
+				infLabels := formatLabels(append(append([]string{}, f.labelNames...), "le"), append(append([]string{}, lv.labelValues...), "+Inf"))
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, infLabels, h.total.Load())
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, labels, formatFloat(h.sum()))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, labels, h.total.Load())
+			}
+		}
+		f.mu.Unlock()
+	}
+	return bw.err
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf("%s=%q", n, values[i])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// errWriter collects the first write error so callers don't need to
+// check every Fprintf individually.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("things_total", "Total things.")
+	g := r.Gauge("widgets", "Current widgets.")
+
+	c.Add(3)
+	g.Set(7)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "things_total 3\n") {
+		t.Errorf("expected counter value in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "widgets 7\n") {
+		t.Errorf("expected gauge value in output, got:\n%s", out)
+	}
+}
+
+func TestCounterVecLabelsAreIndependent(t *testing.T) {
+	r := NewRegistry()
+	v := r.CounterVec("requests_total", "Total requests.", "route")
+
+	v.WithLabelValues("/a").Inc()
+	v.WithLabelValues("/a").Inc()
+	v.WithLabelValues("/b").Inc()
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `requests_total{route="/a"} 2`) {
+		t.Errorf("expected /a count of 2, got:\n%s", out)
+	}
+	if !strings.Contains(out, `requests_total{route="/b"} 1`) {
+		t.Errorf("expected /b count of 1, got:\n%s", out)
+	}
+}
+
+func TestHistogramObserveBucketsAreCumulative(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 0.5, 1})
+
+	h.Observe(0.05)
+	h.Observe(0.3)
+	h.Observe(2)
+
+	if got := h.counts[0].Load(); got != 1 {
+		t.Errorf("bucket 0.1: got %d, want 1", got)
+	}
+	if got := h.counts[1].Load(); got != 2 {
+		t.Errorf("bucket 0.5: got %d, want 2", got)
+	}
+	if got := h.counts[2].Load(); got != 2 {
+		t.Errorf("bucket 1: got %d, want 2", got)
+	}
+	if got := h.total.Load(); got != 3 {
+		t.Errorf("total: got %d, want 3", got)
+	}
+	if got, want := h.sum(), 2.35; got != want {
+		t.Errorf("sum: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// HTTPMetrics holds the request-scoped metrics the Middleware records.
+type HTTPMetrics struct {
+	Requests *CounterVec
+	Latency  *HistogramVec
+	InFlight *Gauge
+}
+
+// NewHTTPMetrics registers the request counter, latency histogram and
+// in-flight gauge on r.
+func NewHTTPMetrics(r *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		Requests: r.CounterVec("http_requests_total", "Total HTTP requests handled.", "method", "route", "status"),
+		Latency:  r.HistogramVec("http_request_duration_seconds", "HTTP request latency.", DefaultLatencyBuckets, "method", "route", "status"),
+		InFlight: r.Gauge("http_requests_in_flight", "Requests currently being served."),
+	}
+}
+
+// Middleware instruments every request with m's counter, histogram and
+// in-flight gauge. It labels by the matched chi route pattern (e.g.
+// "/api/v1/query"), not the raw request path, so that path parameters
+// never inflate label cardinality.
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.InFlight.Inc()
+		defer m.InFlight.Dec()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+		elapsed := time.Since(start)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(ww.Status())
+
+		m.Requests.WithLabelValues(r.Method, route, status).Inc()
+		m.Latency.WithLabelValues(r.Method, route, status).Observe(elapsed.Seconds())
+	})
+}
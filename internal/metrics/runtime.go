@@ -0,0 +1,35 @@
+package metrics
+
+import "runtime"
+
+// RegisterRuntimeCollectors adds gauges for the Go runtime statistics
+// operators expect alongside any other service: live goroutines and the
+// memory allocator's view of heap usage and GC activity. Each is read
+// fresh on every scrape rather than polled continuously.
+func RegisterRuntimeCollectors(r *Registry) {
+	r.GaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+
+	r.GaugeFunc("go_memstats_alloc_bytes", "Bytes of allocated heap objects.", func() float64 {
+		return float64(readMemStats().Alloc)
+	})
+	r.GaugeFunc("go_memstats_sys_bytes", "Bytes obtained from the OS.", func() float64 {
+		return float64(readMemStats().Sys)
+	})
+	r.GaugeFunc("go_memstats_heap_objects", "Number of allocated heap objects.", func() float64 {
+		return float64(readMemStats().HeapObjects)
+	})
+	r.GaugeFunc("go_gc_duration_seconds_sum", "Cumulative time spent in GC stop-the-world pauses.", func() float64 {
+		return float64(readMemStats().PauseTotalNs) / 1e9
+	})
+	r.GaugeFunc("go_gc_cycles_total", "Number of completed GC cycles.", func() float64 {
+		return float64(readMemStats().NumGC)
+	})
+}
+
+func readMemStats() runtime.MemStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms
+}
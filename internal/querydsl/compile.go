@@ -0,0 +1,206 @@
+package querydsl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindTime
+)
+
+type column struct {
+	name string
+	kind columnKind
+}
+
+// fieldColumns whitelists which field: predicates are accepted and which
+// SQL column and comparison semantics each compiles to. This is the only
+// place a parsed field name is allowed to influence which column is
+// touched.
+var fieldColumns = map[string]column{
+	"etld":       {name: "etld", kind: kindString},
+	"first_seen": {name: "first_seen", kind: kindTime},
+	"last_seen":  {name: "last_seen", kind: kindTime},
+}
+
+const defaultColumn = "domain"
+
+var timeLayouts = []string{"2006-01-02", time.RFC3339}
+var timeOps = []string{">=", "<=", ">", "<"}
+
+// Compile translates an AST produced by Parse into a parameterized SQL
+// WHERE clause (placeholders starting at paramOffset+1) and its matching
+// argument list. User text is never interpolated into the returned
+// clause.
+func Compile(n Node, paramOffset int) (string, []interface{}, error) {
+	c := &compiler{paramOffset: paramOffset}
+	clause, err := c.compile(n)
+	if err != nil {
+		return "", nil, err
+	}
+	return clause, c.args, nil
+}
+
+type compiler struct {
+	paramOffset int
+	args        []interface{}
+}
+
+func (c *compiler) addArg(v interface{}) string {
+	c.paramOffset++
+	c.args = append(c.args, v)
+	return fmt.Sprintf("$%d", c.paramOffset)
+}
+
+func (c *compiler) compile(n Node) (string, error) {
+	switch node := n.(type) {
+	case *AndNode:
+		return c.compileBinary(node.Left, node.Right, "AND")
+	case *OrNode:
+		return c.compileBinary(node.Left, node.Right, "OR")
+	case *NotNode:
+		child, err := c.compile(node.Child)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(NOT %s)", child), nil
+	case *TermNode:
+		return c.compileTerm(node)
+	default:
+		return "", fmt.Errorf("querydsl: unknown node type %T", n)
+	}
+}
+
+func (c *compiler) compileBinary(left, right Node, op string) (string, error) {
+	l, err := c.compile(left)
+	if err != nil {
+		return "", err
+	}
+	r, err := c.compile(right)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s %s %s)", l, op, r), nil
+}
+
+func (c *compiler) compileTerm(t *TermNode) (string, error) {
+	if t.Field == "" {
+		return c.compileSubstringTerm(defaultColumn, t)
+	}
+
+	col, ok := fieldColumns[strings.ToLower(t.Field)]
+	if !ok {
+		return "", &ParseError{Pos: t.Pos, Msg: fmt.Sprintf("unknown field %q", t.Field)}
+	}
+
+	if col.kind == kindTime {
+		return c.compileTimeTerm(col.name, t)
+	}
+	return c.compileFieldStringTerm(col.name, t)
+}
+
+// compileSubstringTerm handles default (bareword/quoted) terms against
+// domain: a substring search unless quoted, in which case it's exact.
+func (c *compiler) compileSubstringTerm(column string, t *TermNode) (string, error) {
+	value := strings.ToLower(t.Value)
+	if t.Exact {
+		return fmt.Sprintf("%s = %s", column, c.addArg(value)), nil
+	}
+
+	pattern, hasWildcard := translateGlob(value)
+	if !hasWildcard {
+		pattern = "%" + pattern + "%"
+	}
+	return fmt.Sprintf("%s LIKE %s ESCAPE '\\'", column, c.addArg(pattern)), nil
+}
+
+// compileFieldStringTerm handles a named string field predicate, e.g.
+// etld:com.au: an exact match unless the value itself carries a glob
+// wildcard, in which case it becomes a LIKE.
+func (c *compiler) compileFieldStringTerm(column string, t *TermNode) (string, error) {
+	value := strings.ToLower(t.Value)
+	if t.Exact {
+		return fmt.Sprintf("%s = %s", column, c.addArg(value)), nil
+	}
+
+	if strings.ContainsAny(value, "*?") {
+		pattern, _ := translateGlob(value)
+		return fmt.Sprintf("%s LIKE %s ESCAPE '\\'", column, c.addArg(pattern)), nil
+	}
+	return fmt.Sprintf("%s = %s", column, c.addArg(value)), nil
+}
+
+func (c *compiler) compileTimeTerm(column string, t *TermNode) (string, error) {
+	op := "="
+	value := t.Value
+	for _, candidate := range timeOps {
+		if strings.HasPrefix(value, candidate) {
+			op = candidate
+			value = strings.TrimPrefix(value, candidate)
+			break
+		}
+	}
+
+	var parsed time.Time
+	var err error
+	for _, layout := range timeLayouts {
+		if parsed, err = time.Parse(layout, value); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", &ParseError{Pos: t.Pos, Msg: fmt.Sprintf("invalid date %q for %s", value, column)}
+	}
+
+	return fmt.Sprintf("%s %s %s", column, op, c.addArg(parsed.Unix())), nil
+}
+
+// SubstringPattern lowercases s and escapes it for use as a LIKE pattern
+// matching any domain containing it literally, the same semantics
+// strings.Contains gives a plain (non-DSL) substring filter. Unlike
+// translateGlob, it does not treat * or ? as wildcards, since callers
+// outside the DSL grammar — e.g. the SSE stream's plain substring filter
+// — match those characters literally too.
+func SubstringPattern(s string) string {
+	s = strings.ToLower(s)
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '%', '_', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return "%" + sb.String() + "%"
+}
+
+// translateGlob escapes literal %, _ and \ for use in a LIKE pattern and
+// turns the DSL's glob wildcards * and ? into SQL's % and _. It reports
+// whether a wildcard was present, so the caller can tell an explicit glob
+// from a plain substring search, which is still wrapped in % on both
+// sides.
+func translateGlob(s string) (string, bool) {
+	var sb strings.Builder
+	hasWildcard := false
+	for _, r := range s {
+		switch r {
+		case '%', '_', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '*':
+			sb.WriteByte('%')
+			hasWildcard = true
+		case '?':
+			sb.WriteByte('_')
+			hasWildcard = true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), hasWildcard
+}
@@ -0,0 +1,79 @@
+package querydsl
+
+import (
+	"strings"
+	"unicode"
+)
+
+// lex splits input into tokens. Quoted strings support \"-style escaping
+// of the closing quote and backslashes; anything else is a bareword that
+// runs until whitespace or a structural character ( ) :.
+func lex(input string) ([]token, error) {
+	var toks []token
+	runes := []rune(input)
+	i := 0
+
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(':
+			toks = append(toks, token{tokenLParen, "(", i})
+			i++
+
+		case c == ')':
+			toks = append(toks, token{tokenRParen, ")", i})
+			i++
+
+		case c == ':':
+			toks = append(toks, token{tokenColon, ":", i})
+			i++
+
+		case c == '"':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, &ParseError{Pos: start, Msg: "unterminated quoted string"}
+			}
+			toks = append(toks, token{tokenString, sb.String(), start})
+
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != ':' {
+				i++
+			}
+			word := string(runes[start:i])
+			switch strings.ToUpper(word) {
+			case "AND":
+				toks = append(toks, token{tokenAnd, word, start})
+			case "OR":
+				toks = append(toks, token{tokenOr, word, start})
+			case "NOT":
+				toks = append(toks, token{tokenNot, word, start})
+			default:
+				toks = append(toks, token{tokenIdent, word, start})
+			}
+		}
+	}
+
+	toks = append(toks, token{tokenEOF, "", len(runes)})
+	return toks, nil
+}
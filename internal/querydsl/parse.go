@@ -0,0 +1,163 @@
+package querydsl
+
+import "fmt"
+
+// ParseError reports a syntax or semantic error together with the rune
+// offset of the offending token, so callers can point users at the exact
+// position in their query.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("query: %s (at position %d)", e.Msg, e.Pos)
+}
+
+// Node is a parsed query DSL expression.
+type Node interface{ isNode() }
+
+// AndNode, OrNode and NotNode are the boolean combinators.
+type AndNode struct{ Left, Right Node }
+type OrNode struct{ Left, Right Node }
+type NotNode struct{ Child Node }
+
+// TermNode is a single match: either a default (domain) term or an
+// explicit field:value predicate.
+type TermNode struct {
+	Field string // "" for a default bareword/quoted term
+	Value string
+	Exact bool // quoted: match Value literally, no wildcard translation
+	Pos   int
+}
+
+func (*AndNode) isNode()  {}
+func (*OrNode) isNode()   {}
+func (*NotNode) isNode()  {}
+func (*TermNode) isNode() {}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// Parse parses a query DSL string into an AST; see the package doc for
+// the supported syntax.
+func Parse(input string) (Node, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().typ != tokenEOF {
+		return nil, &ParseError{Pos: p.peek().pos, Msg: fmt.Sprintf("unexpected token %q", p.peek().val)}
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+func (p *parser) next() token { t := p.toks[p.pos]; p.pos++; return t }
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrNode{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for isTermStart(p.peek().typ) {
+		if p.peek().typ == tokenAnd {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndNode{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// isTermStart reports whether t can begin a unary expression: an
+// explicit AND is optional, terms adjacent in the query are implicitly
+// ANDed together.
+func isTermStart(t tokenType) bool {
+	switch t {
+	case tokenIdent, tokenString, tokenLParen, tokenNot, tokenAnd:
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().typ == tokenNot {
+		p.next()
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+	switch tok.typ {
+	case tokenLParen:
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().typ != tokenRParen {
+			return nil, &ParseError{Pos: p.peek().pos, Msg: "expected closing )"}
+		}
+		p.next()
+		return node, nil
+
+	case tokenString:
+		p.next()
+		return &TermNode{Value: tok.val, Exact: true, Pos: tok.pos}, nil
+
+	case tokenIdent:
+		p.next()
+		if p.peek().typ == tokenColon {
+			p.next()
+			valTok := p.peek()
+			switch valTok.typ {
+			case tokenIdent:
+				p.next()
+				return &TermNode{Field: tok.val, Value: valTok.val, Pos: tok.pos}, nil
+			case tokenString:
+				p.next()
+				return &TermNode{Field: tok.val, Value: valTok.val, Exact: true, Pos: tok.pos}, nil
+			default:
+				return nil, &ParseError{Pos: valTok.pos, Msg: "expected a value after ':'"}
+			}
+		}
+		return &TermNode{Value: tok.val, Pos: tok.pos}, nil
+
+	default:
+		return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected token %q", tok.val)}
+	}
+}
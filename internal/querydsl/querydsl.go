@@ -0,0 +1,19 @@
+// Package querydsl implements a small boolean query grammar for
+// /api/v1/query: bare substrings (google), quoted exact matches
+// ("foo.com"), field predicates (etld:com.au, first_seen:>=2024-01-01),
+// AND/OR/NOT, and glob wildcards * and ?. Compile turns the parsed AST
+// into a parameterized SQL WHERE clause against a strict column
+// whitelist; user text is never interpolated into SQL.
+package querydsl
+
+// ParseAndCompile parses input and compiles it straight to a WHERE
+// clause and its argument list, the form most callers want. paramOffset
+// is the number of placeholders already used earlier in the statement (0
+// if none), so the returned clause's placeholders continue from there.
+func ParseAndCompile(input string, paramOffset int) (string, []interface{}, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return "", nil, err
+	}
+	return Compile(node, paramOffset)
+}
@@ -0,0 +1,183 @@
+package querydsl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAndCompileBareword(t *testing.T) {
+	where, args, err := ParseAndCompile("google", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "domain LIKE $1 ESCAPE '\\'" {
+		t.Fatalf("got %q", where)
+	}
+	if len(args) != 1 || args[0] != "%google%" {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestParseAndCompileQuotedExact(t *testing.T) {
+	where, args, err := ParseAndCompile(`"foo.com"`, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "domain = $1" {
+		t.Fatalf("got %q", where)
+	}
+	if len(args) != 1 || args[0] != "foo.com" {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestParseAndCompileWildcard(t *testing.T) {
+	_, args, err := ParseAndCompile("foo*bar", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args[0] != "foo%bar" {
+		t.Fatalf("expected * to translate to %%, got %v", args[0])
+	}
+}
+
+func TestParseAndCompileEscapesLiteralWildcardChars(t *testing.T) {
+	_, args, err := ParseAndCompile("50%_off", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args[0] != `%50\%\_off%` {
+		t.Fatalf("expected literal %% and _ to be escaped, got %v", args[0])
+	}
+}
+
+func TestParseAndCompileFieldPredicate(t *testing.T) {
+	where, args, err := ParseAndCompile("etld:com.au", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "etld = $1" {
+		t.Fatalf("got %q", where)
+	}
+	if args[0] != "com.au" {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestParseAndCompileTimeRange(t *testing.T) {
+	where, args, err := ParseAndCompile("first_seen:>=2024-01-01", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "first_seen >= $1" {
+		t.Fatalf("got %q", where)
+	}
+	if len(args) != 1 {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestParseAndCompileBooleanLogic(t *testing.T) {
+	where, args, err := ParseAndCompile(`etld:com.au AND NOT "bad.com.au"`, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "(etld = $1 AND (NOT domain = $2))" {
+		t.Fatalf("got %q", where)
+	}
+	if len(args) != 2 {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestParseAndCompileImplicitAnd(t *testing.T) {
+	where, _, err := ParseAndCompile("google etld:com.au", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "(domain LIKE $1 ESCAPE '\\' AND etld = $2)" {
+		t.Fatalf("got %q", where)
+	}
+}
+
+func TestParseAndCompileOr(t *testing.T) {
+	where, _, err := ParseAndCompile("foo OR bar", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "(domain LIKE $1 ESCAPE '\\' OR domain LIKE $2 ESCAPE '\\')" {
+		t.Fatalf("got %q", where)
+	}
+}
+
+func TestParseAndCompileParens(t *testing.T) {
+	_, _, err := ParseAndCompile("(foo OR bar) AND etld:com.au", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseAndCompileUnknownField(t *testing.T) {
+	_, _, err := ParseAndCompile("bogus:value", 0)
+	if err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %T", err)
+	}
+	if !strings.Contains(perr.Msg, "bogus") {
+		t.Fatalf("expected error to mention the field name, got %q", perr.Msg)
+	}
+}
+
+func TestParseAndCompileInvalidDate(t *testing.T) {
+	_, _, err := ParseAndCompile("first_seen:>=not-a-date", 0)
+	if err == nil {
+		t.Fatal("expected error for invalid date")
+	}
+}
+
+func TestParseAndCompileUnterminatedQuote(t *testing.T) {
+	_, _, err := ParseAndCompile(`"unterminated`, 0)
+	if err == nil {
+		t.Fatal("expected error for unterminated quote")
+	}
+}
+
+func TestParseAndCompileUnbalancedParen(t *testing.T) {
+	_, _, err := ParseAndCompile("(foo", 0)
+	if err == nil {
+		t.Fatal("expected error for unbalanced parenthesis")
+	}
+}
+
+func TestParseAndCompileParamOffset(t *testing.T) {
+	where, args, err := ParseAndCompile("etld:com.au", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != "etld = $3" {
+		t.Fatalf("got %q", where)
+	}
+	if len(args) != 1 {
+		t.Fatalf("got args %v", args)
+	}
+}
+
+func TestSubstringPattern(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"Google", "%google%"},
+		{"foo_bar", `%foo\_bar%`},
+		{"50%off", `%50\%off%`},
+		{"foo*bar?", "%foo*bar?%"},
+	}
+	for _, c := range cases {
+		if got := SubstringPattern(c.in); got != c.want {
+			t.Errorf("SubstringPattern(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package querydsl
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenColon
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+// token is one lexical unit, with the rune offset into the original
+// query it started at, for error reporting.
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
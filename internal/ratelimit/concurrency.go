@@ -0,0 +1,44 @@
+package ratelimit
+
+import "sync"
+
+// ConcurrencyLimiter bounds how many concurrent units of work (e.g. open
+// SSE streams) a single key may hold open at once, independent of the
+// request-rate GCRA limiters.
+type ConcurrencyLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter allowing up to max
+// concurrent holders per key.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, counts: make(map[string]int)}
+}
+
+// Acquire reserves one slot for key, returning false if key already holds
+// max slots.
+func (c *ConcurrencyLimiter) Acquire(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] >= c.max {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
+
+// Release frees a slot reserved by a prior successful Acquire for key.
+func (c *ConcurrencyLimiter) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+		return
+	}
+	c.counts[key]--
+}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import "testing"
+
+func TestConcurrencyLimiterEnforcesMax(t *testing.T) {
+	c := NewConcurrencyLimiter(2)
+
+	if !c.Acquire("a") {
+		t.Fatal("expected first acquire to succeed")
+	}
+	if !c.Acquire("a") {
+		t.Fatal("expected second acquire to succeed")
+	}
+	if c.Acquire("a") {
+		t.Fatal("expected third acquire to be rejected")
+	}
+
+	c.Release("a")
+	if !c.Acquire("a") {
+		t.Fatal("expected acquire to succeed again after a release")
+	}
+}
+
+func TestConcurrencyLimiterKeysAreIndependent(t *testing.T) {
+	c := NewConcurrencyLimiter(1)
+
+	if !c.Acquire("a") {
+		t.Fatal("expected key a to be acquired")
+	}
+	if !c.Acquire("b") {
+		t.Fatal("expected independent key b to be acquired")
+	}
+}
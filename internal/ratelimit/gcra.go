@@ -0,0 +1,132 @@
+// Package ratelimit implements a GCRA (generic cell rate algorithm) rate
+// limiter keyed by an arbitrary string (usually a remote IP), backed by a
+// bounded in-memory LRU store so memory stays flat no matter how many
+// distinct keys are seen.
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// RateQuota describes a steady rate of one event per Period, with Burst
+// additional events allowed to accumulate while the key is idle.
+type RateQuota struct {
+	Period time.Duration
+	Burst  int
+}
+
+// clock lets tests fake time instead of relying on time.Now.
+type clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Result carries what a caller needs to set X-RateLimit-* and Retry-After
+// response headers.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAt    time.Time
+	RetryAfter time.Duration
+}
+
+type tatEntry struct {
+	key string
+	tat time.Time
+}
+
+// Limiter enforces a single RateQuota across a bounded set of keys using
+// GCRA. Once more than capacity distinct keys are in use, the least
+// recently seen one is evicted to make room.
+type Limiter struct {
+	quota    RateQuota
+	capacity int
+	clock    clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+// NewLimiter builds a Limiter enforcing quota, tracking at most capacity
+// distinct keys at a time.
+func NewLimiter(quota RateQuota, capacity int) *Limiter {
+	return &Limiter{
+		quota:    quota,
+		capacity: capacity,
+		clock:    realClock{},
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Allow reports whether a request for key conforms to the quota. On each
+// hit it computes newTAT = max(now, TAT) + emission_interval, rejecting if
+// newTAT - now > burst_offset, otherwise storing newTAT.
+func (l *Limiter) Allow(key string) Result {
+	now := l.clock.Now()
+	emissionInterval := l.quota.Period
+	// The bucket holds Burst+1 tokens: the steady-rate capacity of one
+	// token plus Burst extra ones that accumulate while the key is idle.
+	burstOffset := emissionInterval * time.Duration(l.quota.Burst+1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tat := now
+	if el, ok := l.entries[key]; ok {
+		tat = el.Value.(*tatEntry).tat
+		l.order.MoveToFront(el)
+	}
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(emissionInterval)
+	if newTAT.Sub(now) > burstOffset {
+		resetAt := tat
+		return Result{
+			Allowed:    false,
+			Limit:      l.quota.Burst + 1,
+			Remaining:  0,
+			ResetAt:    resetAt,
+			RetryAfter: newTAT.Sub(now) - burstOffset,
+		}
+	}
+
+	l.storeLocked(key, newTAT)
+
+	remaining := int((burstOffset - newTAT.Sub(now)) / emissionInterval)
+	return Result{
+		Allowed:   true,
+		Limit:     l.quota.Burst + 1,
+		Remaining: remaining,
+		ResetAt:   newTAT,
+	}
+}
+
+func (l *Limiter) storeLocked(key string, tat time.Time) {
+	if el, ok := l.entries[key]; ok {
+		el.Value.(*tatEntry).tat = tat
+		l.order.MoveToFront(el)
+		return
+	}
+
+	el := l.order.PushFront(&tatEntry{key: key, tat: tat})
+	l.entries[key] = el
+
+	for l.capacity > 0 && l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		if oldest == nil {
+			break
+		}
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*tatEntry).key)
+	}
+}
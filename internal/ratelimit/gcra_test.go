@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(quota RateQuota, capacity int) (*Limiter, *fakeClock) {
+	l := NewLimiter(quota, capacity)
+	fc := &fakeClock{now: time.Unix(1000, 0)}
+	l.clock = fc
+	return l, fc
+}
+
+func TestAllowWithinBurst(t *testing.T) {
+	l, _ := newTestLimiter(RateQuota{Period: time.Second, Burst: 2}, 10)
+
+	for i := 0; i < 3; i++ {
+		if res := l.Allow("a"); !res.Allowed {
+			t.Fatalf("request %d: expected allowed, got throttled", i)
+		}
+	}
+
+	if res := l.Allow("a"); res.Allowed {
+		t.Fatalf("expected 4th immediate request to be throttled")
+	}
+}
+
+func TestAllowResultFields(t *testing.T) {
+	l, fc := newTestLimiter(RateQuota{Period: time.Second, Burst: 2}, 10)
+
+	for i, want := range []int{2, 1, 0} {
+		res := l.Allow("a")
+		if !res.Allowed {
+			t.Fatalf("request %d: expected allowed, got throttled", i)
+		}
+		if res.Remaining != want {
+			t.Errorf("request %d: Remaining = %d, want %d", i, res.Remaining, want)
+		}
+		wantResetAt := fc.now.Add(time.Duration(3-want) * time.Second)
+		if !res.ResetAt.Equal(wantResetAt) {
+			t.Errorf("request %d: ResetAt = %v, want %v", i, res.ResetAt, wantResetAt)
+		}
+	}
+
+	// The bucket is now exhausted until the TAT set by the 3rd request
+	// (now+3s) decays, which is exactly what RetryAfter should reflect.
+	res := l.Allow("a")
+	if res.Allowed {
+		t.Fatalf("expected 4th immediate request to be throttled")
+	}
+	if want := fc.now.Add(3 * time.Second); !res.ResetAt.Equal(want) {
+		t.Errorf("ResetAt = %v, want %v", res.ResetAt, want)
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want %v", res.RetryAfter, time.Second)
+	}
+}
+
+func TestAllowRefillsOverTime(t *testing.T) {
+	l, fc := newTestLimiter(RateQuota{Period: time.Second, Burst: 0}, 10)
+
+	if res := l.Allow("a"); !res.Allowed {
+		t.Fatalf("expected first request to be allowed")
+	}
+	if res := l.Allow("a"); res.Allowed {
+		t.Fatalf("expected immediate second request to be throttled")
+	}
+
+	fc.now = fc.now.Add(time.Second)
+	if res := l.Allow("a"); !res.Allowed {
+		t.Fatalf("expected request to be allowed after one period elapsed")
+	}
+}
+
+func TestAllowKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(RateQuota{Period: time.Second, Burst: 0}, 10)
+
+	if res := l.Allow("a"); !res.Allowed {
+		t.Fatalf("expected key a to be allowed")
+	}
+	if res := l.Allow("b"); !res.Allowed {
+		t.Fatalf("expected independent key b to be allowed")
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	l, _ := newTestLimiter(RateQuota{Period: time.Second, Burst: 0}, 2)
+
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("c") // evicts "a", the least recently used key
+
+	if _, ok := l.entries["a"]; ok {
+		t.Fatalf("expected key a to have been evicted")
+	}
+	if l.order.Len() != 2 {
+		t.Fatalf("expected capacity to be enforced, got %d entries", l.order.Len())
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	cases := []struct {
+		in      string
+		wantErr bool
+		period  time.Duration
+		burst   int
+	}{
+		{"5/s", false, 200 * time.Millisecond, 0},
+		{"5/s:10", false, 200 * time.Millisecond, 10},
+		{"1/m", false, time.Minute, 0},
+		{"bad", true, 0, 0},
+		{"5/d", true, 0, 0},
+		{"0/s", true, 0, 0},
+	}
+
+	for _, c := range cases {
+		q, err := ParseQuota(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseQuota(%q): expected error, got none", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseQuota(%q): unexpected error: %v", c.in, err)
+			continue
+		}
+		if q.Period != c.period || q.Burst != c.burst {
+			t.Errorf("ParseQuota(%q) = %+v, want {Period:%v Burst:%d}", c.in, q, c.period, c.burst)
+		}
+	}
+}
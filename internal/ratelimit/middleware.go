@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc extracts the key (usually a client IP) a request should be rate
+// limited on.
+type KeyFunc func(*http.Request) string
+
+// ClientIP returns the host part of r.RemoteAddr, or, when that immediate
+// peer is in trusted, the first address listed in X-Forwarded-For (if
+// any). trusted may be nil, in which case X-Forwarded-For is never
+// consulted.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if len(trusted) == 0 || !ipInAny(net.ParseIP(host), trusted) {
+		return host
+	}
+
+	fwd := r.Header.Get("X-Forwarded-For")
+	if fwd == "" {
+		return host
+	}
+
+	parts := strings.Split(fwd, ",")
+	return strings.TrimSpace(parts[0])
+}
+
+func ipInAny(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs, as taken from
+// an env var such as LEDGER_WEB_TRUSTED_PROXIES.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var out []*net.IPNet
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Middleware returns chi-style middleware that rejects requests exceeding
+// limiter's quota for the key produced by keyFn, setting the standard
+// X-RateLimit-* and Retry-After headers on every response. onReject, if
+// non-nil, is called once per rejected request, e.g. to increment a
+// metrics counter.
+func Middleware(limiter *Limiter, keyFn KeyFunc, onReject func()) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Allow(keyFn(r))
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(res.ResetAt.Unix(), 10))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(int(res.RetryAfter/time.Second)+1))
+				if onReject != nil {
+					onReject()
+				}
+				http.Error(w, "Throttled", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
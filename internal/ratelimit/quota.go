@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseQuota parses quotas in the form "<count>/<unit>" or
+// "<count>/<unit>:<burst>", e.g. "5/s", "100/m:20". unit is one of s, m, h.
+// When the burst suffix is omitted it defaults to 0 (no burst beyond the
+// steady rate).
+func ParseQuota(s string) (RateQuota, error) {
+	ratePart, burstPart, hasBurst := strings.Cut(s, ":")
+
+	count, unit, ok := strings.Cut(ratePart, "/")
+	if !ok {
+		return RateQuota{}, fmt.Errorf("ratelimit: invalid quota %q, want <count>/<unit>", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return RateQuota{}, fmt.Errorf("ratelimit: invalid quota count %q", count)
+	}
+
+	var unitDuration time.Duration
+	switch unit {
+	case "s":
+		unitDuration = time.Second
+	case "m":
+		unitDuration = time.Minute
+	case "h":
+		unitDuration = time.Hour
+	default:
+		return RateQuota{}, fmt.Errorf("ratelimit: invalid quota unit %q, want s, m or h", unit)
+	}
+
+	burst := 0
+	if hasBurst {
+		burst, err = strconv.Atoi(burstPart)
+		if err != nil || burst < 0 {
+			return RateQuota{}, fmt.Errorf("ratelimit: invalid quota burst %q", burstPart)
+		}
+	}
+
+	return RateQuota{Period: unitDuration / time.Duration(n), Burst: burst}, nil
+}
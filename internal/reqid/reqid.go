@@ -0,0 +1,92 @@
+// Package reqid assigns every inbound request a short, sortable
+// identifier so a single request can be traced across access logs,
+// panic reports and any SQL it issues.
+package reqid
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// HeaderName is the header the request ID is read from and echoed back
+// in on every response.
+const HeaderName = "X-Request-ID"
+
+// externalIDPattern bounds the X-Request-ID values callers may supply
+// that Middleware will pass through instead of minting its own, so a
+// client can't smuggle something unbounded or otherwise unsafe to log
+// and echo back verbatim.
+var externalIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// New mints a ULID: a 48-bit millisecond timestamp followed by 80 bits
+// of randomness, Crockford base32-encoded into 26 characters. Unlike a
+// UUID it sorts lexically in generation order, which is convenient when
+// grepping or tailing access logs.
+func New() string {
+	var b [16]byte
+
+	ms := uint64(time.Now().UnixMilli())
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+
+	// A broken OS RNG leaves nothing sane to do here but proceed with
+	// whatever randomness was read; a low-entropy ID beats failing the
+	// request outright.
+	rand.Read(b[6:])
+
+	return encode(b)
+}
+
+// encode renders the 128 bits of b as the 26-character Crockford
+// base32 ULID encoding (26*5 = 130 bits; the 2 extra bits are a leading
+// zero pad since 128 isn't itself a multiple of 5).
+func encode(b [16]byte) string {
+	var out [26]byte
+
+	var acc uint32
+	accBits := 2 // the leading zero pad is already "in" acc as zero bits
+	outIdx := 0
+	for _, by := range b {
+		acc = acc<<8 | uint32(by)
+		accBits += 8
+		for accBits >= 5 {
+			accBits -= 5
+			out[outIdx] = crockford[(acc>>uint(accBits))&0x1f]
+			outIdx++
+		}
+	}
+	return string(out[:])
+}
+
+type ctxKey struct{}
+
+// FromContext returns the request ID Middleware stored on ctx, or "" if
+// none is present.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Middleware assigns every request an ID: an incoming X-Request-ID
+// header when it matches externalIDPattern, or else a freshly minted
+// ULID. The ID is stored in the request context for handlers to read
+// via FromContext, and echoed back in the response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderName)
+		if !externalIDPattern.MatchString(id) {
+			id = New()
+		}
+		w.Header().Set(HeaderName, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, id)))
+	})
+}
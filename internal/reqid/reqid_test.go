@@ -0,0 +1,88 @@
+package reqid
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// decode reverses encode, independently of how encode is implemented,
+// so the round-trip test below actually exercises the bit packing
+// rather than just checking encode against itself.
+func decode(s string) ([16]byte, bool) {
+	var bits [130]byte // one bit per slot, 2 leading pad + 128 data
+	pos := 0
+	for _, ch := range s {
+		v := strings.IndexRune(crockford, ch)
+		if v < 0 {
+			return [16]byte{}, false
+		}
+		for i := 4; i >= 0; i-- {
+			bits[pos] = byte((v >> uint(i)) & 1)
+			pos++
+		}
+	}
+
+	var out [16]byte
+	for i := 0; i < 16; i++ {
+		var by byte
+		for j := 0; j < 8; j++ {
+			by = by<<1 | bits[2+i*8+j]
+		}
+		out[i] = by
+	}
+	return out, true
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	cases := [][16]byte{
+		{},
+		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0x01, 0x8d, 0x1a, 0x2b, 0x3c, 0x00, 0xde, 0xad, 0xbe, 0xef, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	}
+	for _, b := range cases {
+		s := encode(b)
+		if len(s) != 26 {
+			t.Fatalf("encode(%x) has length %d, want 26", b, len(s))
+		}
+		got, ok := decode(s)
+		if !ok {
+			t.Fatalf("decode(%q) failed", s)
+		}
+		if got != b {
+			t.Fatalf("round trip mismatch: got %x, want %x", got, b)
+		}
+	}
+}
+
+func TestNewIsSortableAndValidAlphabet(t *testing.T) {
+	a := New()
+	time.Sleep(2 * time.Millisecond)
+	b := New()
+
+	if len(a) != 26 || len(b) != 26 {
+		t.Fatalf("expected 26-character IDs, got %d and %d", len(a), len(b))
+	}
+	for _, id := range []string{a, b} {
+		for _, ch := range id {
+			if !strings.ContainsRune(crockford, ch) {
+				t.Fatalf("id %q contains non-Crockford-base32 character %q", id, ch)
+			}
+		}
+	}
+	if a >= b {
+		t.Fatalf("expected IDs minted later to sort after earlier ones: %q >= %q", a, b)
+	}
+}
+
+func TestExternalIDPatternAcceptsOnlyValidIDs(t *testing.T) {
+	if !externalIDPattern.MatchString("abc12345") {
+		t.Fatal("expected 8-character alnum ID to be accepted")
+	}
+	if externalIDPattern.MatchString("short") {
+		t.Fatal("expected sub-8-character ID to be rejected")
+	}
+	if externalIDPattern.MatchString("has a space") {
+		t.Fatal("expected ID with a space to be rejected")
+	}
+}
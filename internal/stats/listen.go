@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// minReconnectInterval and maxReconnectInterval bound pq.Listener's
+// backoff between reconnect attempts if the LISTEN connection drops.
+const (
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	pingInterval         = 90 * time.Second
+)
+
+// Run seeds c with a full recount, then keeps it current by listening on
+// a dedicated LISTEN connection to dsn (separate from the query
+// connection pool) for domain_inserted and etld_changed notifications
+// until ctx is canceled. It also recounts from scratch every
+// recountInterval, and again immediately after any reconnect, so a
+// notification missed while disconnected can never leave the cache
+// permanently stale.
+func (c *Cache) Run(ctx context.Context, dsn string, recountInterval time.Duration) error {
+	if err := c.recount(ctx); err != nil {
+		return err
+	}
+
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stats: listener event: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen("domain_inserted"); err != nil {
+		return err
+	}
+	if err := listener.Listen("etld_changed"); err != nil {
+		return err
+	}
+
+	recountTicker := time.NewTicker(recountInterval)
+	defer recountTicker.Stop()
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			if n == nil {
+				// Connection was lost and has been re-established; a
+				// notification could have been missed in between, so
+				// recount rather than risk permanent drift.
+				if err := c.recount(ctx); err != nil {
+					log.Printf("stats: recount after reconnect failed: %v", err)
+				}
+				continue
+			}
+			switch n.Channel {
+			case "domain_inserted":
+				c.domains.Add(1)
+			case "etld_changed":
+				c.etlds.Add(1)
+			}
+		case <-recountTicker.C:
+			if err := c.recount(ctx); err != nil {
+				log.Printf("stats: periodic recount failed: %v", err)
+			}
+		case <-pingTicker.C:
+			if err := listener.Ping(); err != nil {
+				log.Printf("stats: listener ping: %v", err)
+			}
+		}
+	}
+}
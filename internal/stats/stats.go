@@ -0,0 +1,76 @@
+// Package stats maintains live domain and eTLD counts without repeatedly
+// scanning the domains table. A Cache seeds itself with a single COUNT
+// pair at startup, then stays current by listening for the
+// domain_inserted and etld_changed NOTIFY channels emitted by triggers
+// on the domains table, falling back to a full recount periodically or
+// whenever the LISTEN connection drops.
+package stats
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Snapshot is the current domain/eTLD counts, in the shape served by
+// /api/v1/stats.
+type Snapshot struct {
+	Domains int64 `json:"domains"`
+	ETLDs   int64 `json:"etlds"`
+}
+
+// Cache keeps domains/eTLD counts under atomic.Int64s so Snapshot never
+// blocks on, or races with, the goroutine updating them.
+type Cache struct {
+	db      *sqlx.DB
+	onQuery func(stmtName string, d time.Duration)
+
+	domains atomic.Int64
+	etlds   atomic.Int64
+}
+
+// NewCache returns a Cache backed by db. onQuery, if non-nil, is called
+// after each of a recount's two queries with that statement's name and
+// duration, so recounts
+// show up alongside the rest of the service's SQL duration metrics; it
+// may be nil in tests.
+func NewCache(db *sqlx.DB, onQuery func(stmtName string, d time.Duration)) *Cache {
+	return &Cache{db: db, onQuery: onQuery}
+}
+
+// Snapshot returns the current counts. Before the first successful
+// recount it reads as zero values.
+func (c *Cache) Snapshot() Snapshot {
+	return Snapshot{Domains: c.domains.Load(), ETLDs: c.etlds.Load()}
+}
+
+// recount re-derives both counters from a full scan of the domains
+// table, the same pair of queries the cache replaces as its steady-state
+// update path.
+func (c *Cache) recount(ctx context.Context) error {
+	start := time.Now()
+	var etlds int64
+	if err := c.db.GetContext(ctx, &etlds, "SELECT COUNT(*) FROM (SELECT DISTINCT etld FROM domains) AS temp;"); err != nil {
+		return err
+	}
+	c.observe("recount_etld_count", start)
+
+	start = time.Now()
+	var domains int64
+	if err := c.db.GetContext(ctx, &domains, "SELECT COUNT(*) FROM domains"); err != nil {
+		return err
+	}
+	c.observe("recount_domain_count", start)
+
+	c.etlds.Store(etlds)
+	c.domains.Store(domains)
+	return nil
+}
+
+func (c *Cache) observe(stmtName string, start time.Time) {
+	if c.onQuery != nil {
+		c.onQuery(stmtName, time.Since(start))
+	}
+}
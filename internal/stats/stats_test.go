@@ -0,0 +1,25 @@
+package stats
+
+import "testing"
+
+func TestSnapshotZeroValueBeforeRecount(t *testing.T) {
+	c := NewCache(nil, nil)
+
+	got := c.Snapshot()
+	if got != (Snapshot{}) {
+		t.Fatalf("expected zero-value snapshot before any recount, got %+v", got)
+	}
+}
+
+func TestSnapshotReflectsNotificationIncrements(t *testing.T) {
+	c := NewCache(nil, nil)
+
+	c.domains.Add(3)
+	c.etlds.Add(1)
+
+	got := c.Snapshot()
+	want := Snapshot{Domains: 3, ETLDs: 1}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
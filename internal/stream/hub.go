@@ -0,0 +1,91 @@
+// Package stream implements a broadcast hub for newly discovered domains.
+// A single PostgreSQL LISTEN connection feeds the hub, which fans each
+// record out to every subscribed HTTP handler over a small bounded
+// channel, dropping updates for subscribers that fall behind rather than
+// blocking the publisher.
+package stream
+
+import "sync"
+
+// subscriberBuffer bounds how many undelivered records a slow subscriber
+// may accumulate before further records are dropped for it.
+const subscriberBuffer = 32
+
+// Record mirrors the fields of a domains row, in the shape delivered to
+// SSE clients.
+type Record struct {
+	ID        uint64 `json:"id"`
+	Domain    string `json:"domain"`
+	ETLD      string `json:"etld"`
+	FirstSeen int64  `json:"first_seen"`
+	LastSeen  int64  `json:"last_seen"`
+}
+
+// Subscriber receives Records published to a Hub, optionally restricted
+// by the filter function passed to Subscribe.
+type Subscriber struct {
+	ch     chan Record
+	filter func(Record) bool
+}
+
+// C returns the channel new Records are delivered on. It is closed once
+// the subscriber is unsubscribed.
+func (s *Subscriber) C() <-chan Record { return s.ch }
+
+// Hub fans out published Records to every current Subscriber.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewHub returns an empty Hub ready to accept subscribers and publishes.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscriber]struct{})}
+}
+
+// Subscribe registers a new Subscriber. When filter is non-nil, only
+// Records for which it returns true are delivered.
+func (h *Hub) Subscribe(filter func(Record) bool) *Subscriber {
+	s := &Subscriber{ch: make(chan Record, subscriberBuffer), filter: filter}
+
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s
+}
+
+// Unsubscribe removes s from the Hub and closes its channel. It must be
+// called exactly once per Subscriber, typically via defer.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, s)
+	h.mu.Unlock()
+
+	close(s.ch)
+}
+
+// Publish delivers r to every matching Subscriber. Subscribers whose
+// buffer is full are skipped rather than blocking the publisher.
+func (h *Hub) Publish(r Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		if s.filter != nil && !s.filter(r) {
+			continue
+		}
+		select {
+		case s.ch <- r:
+		default:
+			// Slow consumer: drop this record rather than block.
+		}
+	}
+}
+
+// Subscribers reports how many subscribers are currently registered.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
@@ -0,0 +1,71 @@
+package stream
+
+import "testing"
+
+func TestPublishDeliversToSubscriber(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(nil)
+	defer h.Unsubscribe(s)
+
+	h.Publish(Record{ID: 1, Domain: "example.com.au"})
+
+	select {
+	case rec := <-s.C():
+		if rec.ID != 1 {
+			t.Fatalf("got record %+v, want ID 1", rec)
+		}
+	default:
+		t.Fatal("expected record to be delivered")
+	}
+}
+
+func TestPublishHonorsFilter(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(func(r Record) bool { return r.Domain == "match.com.au" })
+	defer h.Unsubscribe(s)
+
+	h.Publish(Record{ID: 1, Domain: "nomatch.com.au"})
+	h.Publish(Record{ID: 2, Domain: "match.com.au"})
+
+	rec := <-s.C()
+	if rec.ID != 2 {
+		t.Fatalf("got record %+v, want only the matching ID 2", rec)
+	}
+
+	select {
+	case rec := <-s.C():
+		t.Fatalf("unexpected extra record %+v", rec)
+	default:
+	}
+}
+
+func TestPublishDropsForFullSubscriber(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(nil)
+	defer h.Unsubscribe(s)
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		h.Publish(Record{ID: uint64(i)})
+	}
+
+	if got := len(s.ch); got != subscriberBuffer {
+		t.Fatalf("expected channel to be capped at %d, got %d", subscriberBuffer, got)
+	}
+}
+
+func TestUnsubscribeRemovesSubscriber(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(nil)
+	if got := h.Subscribers(); got != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", got)
+	}
+
+	h.Unsubscribe(s)
+	if got := h.Subscribers(); got != 0 {
+		t.Fatalf("expected 0 subscribers after Unsubscribe, got %d", got)
+	}
+
+	if _, ok := <-s.C(); ok {
+		t.Fatal("expected subscriber channel to be closed")
+	}
+}
@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// minReconnectInterval and maxReconnectInterval bound pq.Listener's
+// backoff between reconnect attempts if the LISTEN connection drops.
+const (
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	pingInterval         = 90 * time.Second
+)
+
+// Listen opens a dedicated LISTEN connection on dsn (separate from the
+// query connection pool, since LISTEN/NOTIFY requires pinning a single
+// backend connection) and publishes every new_domain notification to hub
+// until ctx is canceled.
+func Listen(ctx context.Context, dsn string, hub *Hub) error {
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stream: listener event: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen("new_domain"); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			if n == nil {
+				// Connection was lost and has been re-established; there
+				// is nothing to replay, live notifications resume as
+				// normal.
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal([]byte(n.Extra), &rec); err != nil {
+				log.Printf("stream: bad new_domain payload: %v", err)
+				continue
+			}
+			hub.Publish(rec)
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				log.Printf("stream: listener ping: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausdomainledger/web/internal/querydsl"
+	"github.com/ausdomainledger/web/internal/ratelimit"
+	"github.com/ausdomainledger/web/internal/stream"
+)
+
+const (
+	streamHeartbeat       = 15 * time.Second
+	streamBackfillLimit   = 500
+	defaultStreamMaxPerIP = 3
+)
+
+var (
+	streamHub         *stream.Hub
+	streamConcurrency *ratelimit.ConcurrencyLimiter
+)
+
+// checkStreamLimit caps how many concurrent /stream connections a single
+// IP may hold open, on top of the regular request-rate limiters.
+func checkStreamLimit(next http.Handler) http.Handler {
+	if throttleDisabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientIPKey(r)
+		if !streamConcurrency.Acquire(key) {
+			throttleRejected.Inc()
+			http.Error(w, "Too many concurrent streams", http.StatusTooManyRequests)
+			return
+		}
+		defer streamConcurrency.Release(key)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	qs := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("query")))
+	if qs != "" {
+		if len(qs) > 255 {
+			http.Error(w, "Query too long", http.StatusBadRequest)
+			return
+		}
+		if len(qs) < 3 {
+			http.Error(w, "Query must be at least 3 characters", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var filter func(stream.Record) bool
+	if qs != "" {
+		filter = func(rec stream.Record) bool {
+			return strings.Contains(strings.ToLower(rec.Domain), qs)
+		}
+	}
+
+	sub := streamHub.Subscribe(filter)
+	defer streamHub.Unsubscribe(sub)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		backfill, err := backfillDomains(r.Context(), lastID, qs)
+		if err != nil {
+			log.Printf("stream: backfill failed: %v", err)
+		}
+		for _, rec := range backfill {
+			writeSSERecord(w, toStreamRecord(rec))
+		}
+		flusher.Flush()
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case rec, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			writeSSERecord(w, rec)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSERecord(w http.ResponseWriter, rec stream.Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: domain\ndata: %s\n\n", rec.ID, data)
+}
+
+func toStreamRecord(r queryResult) stream.Record {
+	return stream.Record{
+		ID:        r.Id,
+		Domain:    r.Domain,
+		ETLD:      r.ETLD,
+		FirstSeen: r.FirstSeen,
+		LastSeen:  r.LastSeen,
+	}
+}
+
+// backfillDomains fetches rows inserted after afterID, for SSE clients
+// resuming via Last-Event-ID. qs, if non-empty, is matched with the same
+// "domain contains qs" semantics as the live stream's strings.Contains
+// filter, via querydsl.SubstringPattern.
+func backfillDomains(ctx context.Context, afterID uint64, qs string) ([]queryResult, error) {
+	var out []queryResult
+	var err error
+	if qs != "" {
+		pattern := querydsl.SubstringPattern(qs)
+		err = dbSelect(ctx, "stream_backfill_filtered", "SELECT * FROM domains WHERE id > $1 AND domain LIKE $2 ESCAPE '\\' ORDER BY id ASC LIMIT $3;", &out, afterID, pattern, streamBackfillLimit)
+	} else {
+		err = dbSelect(ctx, "stream_backfill", "SELECT * FROM domains WHERE id > $1 ORDER BY id ASC LIMIT $2;", &out, afterID, streamBackfillLimit)
+	}
+	return out, err
+}
+
+func streamMaxPerIP() int {
+	if v := os.Getenv("LEDGER_WEB_STREAM_MAX_PER_IP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultStreamMaxPerIP
+}